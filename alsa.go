@@ -53,22 +53,70 @@ var (
 	ErrUnderrun = errors.New("underrun")
 )
 
+// RateMode controls how a requested sample rate is applied to a device.
+type RateMode int
+
+const (
+	// RateExact requires the device to support the requested rate exactly.
+	RateExact RateMode = iota
+	// RateNear allows ALSA to pick the closest rate the device supports, enabling
+	// resampling (see BufferParams.AllowRateResample) when the hardware doesn't support
+	// the requested rate natively.
+	RateNear
+)
+
+// AccessMode selects the ALSA access pattern used to read/write samples. Read and Write
+// transfer samples via snd_pcm_readi/snd_pcm_writei, so only access modes those calls accept
+// are offered here; RW_NONINTERLEAVED would require the snd_pcm_readn/writen family instead
+// and isn't supported.
+type AccessMode int
+
+const (
+	// AccessInterleaved is the default RW_INTERLEAVED access used by Read/Write.
+	AccessInterleaved AccessMode = iota
+	// AccessMMapInterleaved requests MMAP_INTERLEAVED access for lower latency.
+	AccessMMapInterleaved
+)
+
 // BufferParams specifies the buffer parameters of a device.
 // You do not need to specify all the fields, if you set the BufferParams to 0, default values are used
 type BufferParams struct {
 	BufferFrames int
 	PeriodFrames int
 	Periods      int
+
+	// AllowRateResample enables ALSA's internal resampling plugin (snd_pcm_hw_params_set_rate_resample)
+	// so a rate that doesn't match the hardware exactly can still be requested. When false,
+	// the resampling setting is left at ALSA's default, which has resampling enabled; false
+	// does not turn resampling off.
+	AllowRateResample bool
+	// RateMode selects whether the requested rate must match exactly or may be rounded to
+	// the nearest rate the device supports. Defaults to RateExact.
+	RateMode RateMode
+	// AccessMode selects the ALSA access pattern. Defaults to AccessInterleaved.
+	AccessMode AccessMode
+}
+
+func (m AccessMode) toALSA() C.snd_pcm_access_t {
+	switch m {
+	case AccessMMapInterleaved:
+		return C.SND_PCM_ACCESS_MMAP_INTERLEAVED
+	default:
+		return C.SND_PCM_ACCESS_RW_INTERLEAVED
+	}
 }
 
 type device struct {
-	h            *C.snd_pcm_t
-	Channels     int
-	Format       Format
-	Rate         int
-	BufferParams BufferParams
-	frames       int
-	readerThread *C.reader_thread_state
+	h              *C.snd_pcm_t
+	Channels       int
+	Format         Format
+	Rate           int
+	BufferParams   BufferParams
+	frames         int
+	readerThread   *C.reader_thread_state
+	playback       bool
+	recoveryPolicy *RecoveryPolicy
+	canPause       bool
 }
 
 func createError(errorMsg string, errorCode C.int) (err error) {
@@ -100,7 +148,15 @@ func (d *device) createDevice(deviceName string, channels int, format Format, ra
 	if ret < 0 {
 		return createError("could not set default hw params", ret)
 	}
-	ret = C.snd_pcm_hw_params_set_access(d.h, hwParams, C.SND_PCM_ACCESS_RW_INTERLEAVED)
+	// Only override ALSA's own default (resampling enabled) when the caller explicitly
+	// asked for it; leaving BufferParams zeroed must preserve existing behavior.
+	if bufferParams.AllowRateResample {
+		ret = C.snd_pcm_hw_params_set_rate_resample(d.h, hwParams, 1)
+		if ret < 0 {
+			return createError("could not set rate resample params", ret)
+		}
+	}
+	ret = C.snd_pcm_hw_params_set_access(d.h, hwParams, bufferParams.AccessMode.toALSA())
 	if ret < 0 {
 		return createError("could not set access params", ret)
 	}
@@ -112,10 +168,16 @@ func (d *device) createDevice(deviceName string, channels int, format Format, ra
 	if ret < 0 {
 		return createError("could not set channels params", ret)
 	}
-	ret = C.snd_pcm_hw_params_set_rate(d.h, hwParams, C.uint(rate), 0)
+	actualRate := C.uint(rate)
+	if bufferParams.RateMode == RateNear {
+		ret = C.snd_pcm_hw_params_set_rate_near(d.h, hwParams, &actualRate, nil)
+	} else {
+		ret = C.snd_pcm_hw_params_set_rate(d.h, hwParams, actualRate, 0)
+	}
 	if ret < 0 {
 		return createError("could not set rate params", ret)
 	}
+	rate = int(actualRate)
 
 	/*
 		// set the buffer time
@@ -210,13 +272,18 @@ func (d *device) createDevice(deviceName string, channels int, format Format, ra
 	if ret < 0 {
 		return createError("could not set hw params", ret)
 	}
+	d.canPause = C.snd_pcm_hw_params_can_pause(hwParams) == 1
 	d.frames = int(periodFrames)
 	d.Channels = channels
 	d.Format = format
 	d.Rate = rate
+	d.playback = playback
 	d.BufferParams.BufferFrames = int(bufferSize)
 	d.BufferParams.PeriodFrames = int(periodFrames)
 	d.BufferParams.Periods = int(periods)
+	d.BufferParams.AllowRateResample = bufferParams.AllowRateResample
+	d.BufferParams.RateMode = bufferParams.RateMode
+	d.BufferParams.AccessMode = bufferParams.AccessMode
 	return
 }
 
@@ -320,18 +387,31 @@ func (c *CaptureDevice) Read(buffer interface{}) (samples int, err error) {
 		}
 		rc := C.reader_thread_poll(c.readerThread, bufPtr)
 		if rc == 1 {
+			if policy := c.policy(); policy.OnXrun != nil {
+				policy.OnXrun(XrunOverrun)
+			}
 			return 0, ErrOverrun
 		} else if rc != 0 {
 			return 0, fmt.Errorf("read error: %s", C.GoString(C.reader_thread_error))
 		}
 		samples = frames * c.Channels
 	} else {
-		ret := C.snd_pcm_readi(c.h, bufPtr, C.snd_pcm_uframes_t(frames))
-
-		if ret == -C.EPIPE {
-			C.snd_pcm_prepare(c.h)
-			return 0, ErrOverrun
-		} else if ret < 0 {
+		frameCount := C.snd_pcm_uframes_t(frames)
+		policy := c.policy()
+		var ret C.long
+		for attempt := 0; ; attempt++ {
+			ret = C.snd_pcm_readi(c.h, bufPtr, frameCount)
+			if ret != -C.EPIPE && ret != -C.ESTRPIPE {
+				break
+			}
+			if err = c.recover(C.int(ret)); err != nil {
+				return 0, err
+			}
+			if attempt >= policy.MaxUnderrunRetries {
+				return 0, ErrOverrun
+			}
+		}
+		if ret < 0 {
 			return 0, createError("read error", C.int(ret))
 		}
 		samples = int(ret) * c.Channels
@@ -391,11 +471,21 @@ func (p *PlaybackDevice) Write(buffer interface{}) (samples int, err error) {
 	var frames = C.snd_pcm_uframes_t(length / p.Channels)
 	bufPtr := unsafe.Pointer(sliceData.Index(0).Addr().Pointer())
 
-	ret := C.snd_pcm_writei(p.h, bufPtr, frames)
-	if ret == -C.EPIPE {
-		C.snd_pcm_prepare(p.h)
-		return 0, ErrUnderrun
-	} else if ret < 0 {
+	policy := p.policy()
+	var ret C.long
+	for attempt := 0; ; attempt++ {
+		ret = C.snd_pcm_writei(p.h, bufPtr, frames)
+		if ret != -C.EPIPE && ret != -C.ESTRPIPE {
+			break
+		}
+		if err = p.recover(C.int(ret)); err != nil {
+			return 0, err
+		}
+		if attempt >= policy.MaxUnderrunRetries {
+			return 0, ErrUnderrun
+		}
+	}
+	if ret < 0 {
 		return 0, createError("write error", C.int(ret))
 	}
 	samples = int(ret) * p.Channels
@@ -0,0 +1,108 @@
+// Copyright 2015-2016 Cocoon Labs Ltd.
+//
+// See LICENSE file for terms and conditions.
+
+package alsa
+
+import (
+	"unsafe"
+)
+
+/*
+#include <alsa/asoundlib.h>
+*/
+import "C"
+
+// standardRates is the set of sample rates probed by QueryCapabilities.
+var standardRates = []int{8000, 11025, 16000, 22050, 32000, 44100, 48000, 88200, 96000, 176400, 192000}
+
+// allFormats is the set of sample formats probed by QueryCapabilities.
+var allFormats = []Format{
+	FormatS8, FormatU8,
+	FormatS16LE, FormatS16BE, FormatU16LE, FormatU16BE,
+	FormatS24LE, FormatS24BE, FormatU24LE, FormatU24BE,
+	FormatS32LE, FormatS32BE, FormatU32LE, FormatU32BE,
+	FormatFloatLE, FormatFloatBE, FormatFloat64LE, FormatFloat64BE,
+}
+
+// DeviceCapabilities describes the formats, channel counts and sample rates a device
+// supports, as reported by QueryCapabilities.
+type DeviceCapabilities struct {
+	Formats        []Format
+	MinChannels    int
+	MaxChannels    int
+	MinRate        int
+	MaxRate        int
+	SupportedRates []int
+}
+
+// QueryCapabilities opens deviceName in non-blocking mode and probes the hardware
+// parameters ALSA will accept, without committing them to the device. playback selects
+// between the playback and capture streams.
+func QueryCapabilities(deviceName string, playback bool) (*DeviceCapabilities, error) {
+	deviceCString := C.CString(deviceName)
+	defer C.free(unsafe.Pointer(deviceCString))
+
+	var h *C.snd_pcm_t
+	var ret C.int
+	if playback {
+		ret = C.snd_pcm_open(&h, deviceCString, C.SND_PCM_STREAM_PLAYBACK, C.SND_PCM_NONBLOCK)
+	} else {
+		ret = C.snd_pcm_open(&h, deviceCString, C.SND_PCM_STREAM_CAPTURE, C.SND_PCM_NONBLOCK)
+	}
+	if ret < 0 {
+		return nil, createError("could not open ALSA device", ret)
+	}
+	defer C.snd_pcm_close(h)
+
+	var hwParams *C.snd_pcm_hw_params_t
+	ret = C.snd_pcm_hw_params_malloc(&hwParams)
+	if ret < 0 {
+		return nil, createError("could not alloc hw params", ret)
+	}
+	defer C.snd_pcm_hw_params_free(hwParams)
+	ret = C.snd_pcm_hw_params_any(h, hwParams)
+	if ret < 0 {
+		return nil, createError("could not set default hw params", ret)
+	}
+
+	caps := &DeviceCapabilities{}
+
+	for _, f := range allFormats {
+		if C.snd_pcm_hw_params_test_format(h, hwParams, C.snd_pcm_format_t(f)) == 0 {
+			caps.Formats = append(caps.Formats, f)
+		}
+	}
+
+	var minChannels, maxChannels C.uint
+	ret = C.snd_pcm_hw_params_get_channels_min(hwParams, &minChannels)
+	if ret < 0 {
+		return nil, createError("could not get min channels", ret)
+	}
+	ret = C.snd_pcm_hw_params_get_channels_max(hwParams, &maxChannels)
+	if ret < 0 {
+		return nil, createError("could not get max channels", ret)
+	}
+	caps.MinChannels = int(minChannels)
+	caps.MaxChannels = int(maxChannels)
+
+	var minRate, maxRate C.uint
+	ret = C.snd_pcm_hw_params_get_rate_min(hwParams, &minRate, nil)
+	if ret < 0 {
+		return nil, createError("could not get min rate", ret)
+	}
+	ret = C.snd_pcm_hw_params_get_rate_max(hwParams, &maxRate, nil)
+	if ret < 0 {
+		return nil, createError("could not get max rate", ret)
+	}
+	caps.MinRate = int(minRate)
+	caps.MaxRate = int(maxRate)
+
+	for _, rate := range standardRates {
+		if C.snd_pcm_hw_params_test_rate(h, hwParams, C.uint(rate), 0) == 0 {
+			caps.SupportedRates = append(caps.SupportedRates, rate)
+		}
+	}
+
+	return caps, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2015-2016 Cocoon Labs Ltd.
+//
+// See LICENSE file for terms and conditions.
+
+package alsa
+
+import (
+	"unsafe"
+)
+
+/*
+#include <alsa/asoundlib.h>
+#include <stdlib.h>
+*/
+import "C"
+
+// DeviceInfo describes a single PCM device hint as reported by ALSA.
+type DeviceInfo struct {
+	// Name is the device identifier to pass to NewPlaybackDevice/NewCaptureDevice.
+	Name string
+	// Description is a human readable description of the device, if any.
+	Description string
+	// Playback is true if the device supports playback.
+	Playback bool
+	// Capture is true if the device supports capture.
+	Capture bool
+}
+
+// ListDevices returns every PCM device hint known to ALSA, regardless of direction.
+func ListDevices() ([]DeviceInfo, error) {
+	return listDevices("")
+}
+
+// ListPlaybackDevices returns the PCM devices that support playback.
+func ListPlaybackDevices() ([]DeviceInfo, error) {
+	devices, err := listDevices("Output")
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// ListCaptureDevices returns the PCM devices that support capture.
+func ListCaptureDevices() ([]DeviceInfo, error) {
+	devices, err := listDevices("Input")
+	if err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// listDevices queries snd_device_name_hint for the given direction ("Output", "Input" or
+// "" for both) and returns the resulting hints.
+func listDevices(ioid string) ([]DeviceInfo, error) {
+	pcmCString := C.CString("pcm")
+	defer C.free(unsafe.Pointer(pcmCString))
+
+	var hints *unsafe.Pointer
+	ret := C.snd_device_name_hint(-1, pcmCString, &hints)
+	if ret < 0 {
+		return nil, createError("could not enumerate devices", ret)
+	}
+	defer C.snd_device_name_free_hint(hints)
+
+	nameKey := C.CString("NAME")
+	defer C.free(unsafe.Pointer(nameKey))
+	descKey := C.CString("DESC")
+	defer C.free(unsafe.Pointer(descKey))
+	ioidKey := C.CString("IOID")
+	defer C.free(unsafe.Pointer(ioidKey))
+
+	var devices []DeviceInfo
+	for p := (*[1 << 28]unsafe.Pointer)(unsafe.Pointer(hints))[:]; p[0] != nil; p = p[1:] {
+		hint := p[0]
+
+		nameCString := C.snd_device_name_get_hint(hint, nameKey)
+		if nameCString == nil {
+			continue
+		}
+		name := C.GoString(nameCString)
+		C.free(unsafe.Pointer(nameCString))
+
+		descCString := C.snd_device_name_get_hint(hint, descKey)
+		desc := ""
+		if descCString != nil {
+			desc = C.GoString(descCString)
+			C.free(unsafe.Pointer(descCString))
+		}
+
+		playback, capture := true, true
+		ioidCString := C.snd_device_name_get_hint(hint, ioidKey)
+		if ioidCString != nil {
+			switch C.GoString(ioidCString) {
+			case "Output":
+				capture = false
+			case "Input":
+				playback = false
+			}
+			C.free(unsafe.Pointer(ioidCString))
+		}
+
+		if ioid == "Output" && !playback {
+			continue
+		}
+		if ioid == "Input" && !capture {
+			continue
+		}
+
+		devices = append(devices, DeviceInfo{
+			Name:        name,
+			Description: desc,
+			Playback:    playback,
+			Capture:     capture,
+		})
+	}
+
+	return devices, nil
+}
+
+// DefaultPlaybackDevice returns the name of the default playback device, preferring the
+// ALSA "default" PCM if it is present and otherwise falling back to the first device hint
+// that supports playback.
+func DefaultPlaybackDevice() (string, error) {
+	return defaultDevice("Output")
+}
+
+// DefaultCaptureDevice returns the name of the default capture device, preferring the ALSA
+// "default" PCM if it is present and otherwise falling back to the first device hint that
+// supports capture.
+func DefaultCaptureDevice() (string, error) {
+	return defaultDevice("Input")
+}
+
+func defaultDevice(ioid string) (string, error) {
+	devices, err := listDevices(ioid)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range devices {
+		if d.Name == "default" {
+			return d.Name, nil
+		}
+	}
+	if len(devices) > 0 {
+		return devices[0].Name, nil
+	}
+	return "default", nil
+}
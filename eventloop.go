@@ -0,0 +1,295 @@
+// Copyright 2015-2016 Cocoon Labs Ltd.
+//
+// See LICENSE file for terms and conditions.
+
+package alsa
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+/*
+#include <alsa/asoundlib.h>
+#include <poll.h>
+#include <unistd.h>
+*/
+import "C"
+
+// StreamID identifies a stream added to an EventLoop.
+type StreamID int
+
+// errStreamClosed is returned by Pause/Resume when the id does not refer to a live stream.
+var errStreamClosed = errors.New("alsa: unknown or closed stream")
+
+type eventStream struct {
+	id     StreamID
+	d      *device
+	paused bool
+	// bytesPerFrame is FormatSampleSize(format) * channels, used to convert the byte
+	// counts the callbacks deal in to the frame counts ALSA deals in.
+	bytesPerFrame int
+	// writeCallback fills buf with up to len(buf) bytes and returns the number of bytes
+	// written; it is used for playback streams.
+	writeCallback func(buf []byte) (n int, err error)
+	// readCallback receives the bytes read from a capture stream.
+	readCallback func(buf []byte)
+	scratch      []byte
+}
+
+// EventLoop drives any number of playback and capture streams from a single OS thread
+// using poll(2), in the style of cpal's ALSA host. Streams are added with AddPlayback or
+// AddCapture and are serviced once Run is called; Run blocks until Close is called.
+type EventLoop struct {
+	mu      sync.Mutex
+	streams map[StreamID]*eventStream
+	nextID  StreamID
+
+	// pipeRead/pipeWrite are a self-pipe used to wake a blocking poll(2) when Pause,
+	// Resume or Close mutate the stream set from another goroutine.
+	pipeRead  C.int
+	pipeWrite C.int
+
+	closed bool
+}
+
+// NewEventLoop creates an EventLoop ready to have streams added to it.
+func NewEventLoop() (*EventLoop, error) {
+	var fds [2]C.int
+	if ret := C.pipe(&fds[0]); ret < 0 {
+		return nil, createError("could not create wakeup pipe", ret)
+	}
+	return &EventLoop{
+		streams:   make(map[StreamID]*eventStream),
+		pipeRead:  fds[0],
+		pipeWrite: fds[1],
+	}, nil
+}
+
+// AddPlayback registers a playback device with the event loop. callback is invoked with a
+// buffer to fill whenever p is ready to accept more samples; it returns the number of bytes
+// actually written.
+func (e *EventLoop) AddPlayback(p *PlaybackDevice, callback func(buf []byte) (n int, err error)) (StreamID, error) {
+	return e.addStream(&p.device, callback, nil)
+}
+
+// AddCapture registers a capture device with the event loop. callback is invoked with the
+// bytes read from c whenever data is available.
+func (e *EventLoop) AddCapture(c *CaptureDevice, callback func(buf []byte)) (StreamID, error) {
+	return e.addStream(&c.device, nil, callback)
+}
+
+func (e *EventLoop) addStream(d *device, writeCallback func([]byte) (int, error), readCallback func([]byte)) (StreamID, error) {
+	bytesPerFrame := FormatSampleSize(d.Format) * d.Channels
+	periodBytes := bytesPerFrame * d.BufferParams.PeriodFrames
+	if periodBytes <= 0 {
+		return 0, errors.New("alsa: device has no period size configured")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := e.nextID
+	e.nextID++
+	e.streams[id] = &eventStream{
+		id:            id,
+		d:             d,
+		bytesPerFrame: bytesPerFrame,
+		writeCallback: writeCallback,
+		readCallback:  readCallback,
+		scratch:       make([]byte, periodBytes),
+	}
+	e.wake()
+	return id, nil
+}
+
+// Pause stops an EventLoop from servicing the given stream until Resume is called.
+func (e *EventLoop) Pause(id StreamID) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.streams[id]
+	if !ok {
+		return errStreamClosed
+	}
+	s.paused = true
+	e.wake()
+	return nil
+}
+
+// Resume resumes servicing a stream previously paused with Pause.
+func (e *EventLoop) Resume(id StreamID) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.streams[id]
+	if !ok {
+		return errStreamClosed
+	}
+	s.paused = false
+	e.wake()
+	return nil
+}
+
+// Close stops Run. The wakeup pipe is closed by Run as it returns, so it stays open if Run
+// was never called. Close does not close the underlying devices.
+func (e *EventLoop) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.wake()
+	e.mu.Unlock()
+	return nil
+}
+
+// wake writes a single byte to the self-pipe so a blocking poll(2) in Run returns and
+// re-reads the current stream set. Callers must hold e.mu.
+func (e *EventLoop) wake() {
+	var b C.char
+	C.write(e.pipeWrite, unsafe.Pointer(&b), 1)
+}
+
+// drainWake consumes every byte buffered in the self-pipe.
+func (e *EventLoop) drainWake() {
+	var buf [64]C.char
+	for C.read(e.pipeRead, unsafe.Pointer(&buf[0]), 64) == 64 {
+	}
+}
+
+// streamSet is a snapshot of the streams Run should poll this iteration, together with the
+// range of the shared pollfd array each one owns.
+type streamSet struct {
+	pfds    []C.struct_pollfd
+	streams []*eventStream
+	starts  []int
+	counts  []int
+}
+
+// snapshot builds the pollfd array for every active (non-paused) stream, plus the self-pipe
+// wakeup fd at index 0.
+func (e *EventLoop) snapshot() (*streamSet, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	set := &streamSet{pfds: []C.struct_pollfd{{fd: e.pipeRead, events: C.POLLIN}}}
+	for _, s := range e.streams {
+		if s.paused {
+			continue
+		}
+		pfds, err := pcmPollDescriptors(s.d.h)
+		if err != nil {
+			return nil, err
+		}
+		set.streams = append(set.streams, s)
+		set.starts = append(set.starts, len(set.pfds))
+		set.counts = append(set.counts, len(pfds))
+		set.pfds = append(set.pfds, pfds...)
+	}
+	return set, nil
+}
+
+// Run services every registered stream until Close is called, blocking the calling
+// goroutine. It is intended to be run on its own OS thread (e.g. via go e.Run() from the
+// caller).
+func (e *EventLoop) Run() error {
+	for {
+		e.mu.Lock()
+		closed := e.closed
+		e.mu.Unlock()
+		if closed {
+			C.close(e.pipeRead)
+			C.close(e.pipeWrite)
+			return nil
+		}
+
+		set, err := e.snapshot()
+		if err != nil {
+			return err
+		}
+
+		ret := C.poll(&set.pfds[0], C.nfds_t(len(set.pfds)), -1)
+		if ret < 0 {
+			return createError("poll failed", C.int(ret))
+		}
+
+		if set.pfds[0].revents != 0 {
+			e.drainWake()
+		}
+
+		for i, s := range set.streams {
+			start, count := set.starts[i], set.counts[i]
+			var revents C.ushort
+			rc := C.snd_pcm_poll_descriptors_revents(s.d.h, &set.pfds[start], C.uint(count), &revents)
+			if rc < 0 {
+				continue
+			}
+			if revents&(C.POLLERR|C.POLLNVAL) != 0 {
+				recoverStream(s)
+				continue
+			}
+			if s.d.playback && revents&C.POLLOUT != 0 {
+				e.serviceWrite(s)
+			}
+			if !s.d.playback && revents&C.POLLIN != 0 {
+				e.serviceRead(s)
+			}
+		}
+	}
+}
+
+// serviceWrite asks the user callback for samples and writes them to the device.
+func (e *EventLoop) serviceWrite(s *eventStream) {
+	n, err := s.writeCallback(s.scratch)
+	if err != nil || n <= 0 {
+		return
+	}
+	frames := C.snd_pcm_uframes_t(n / s.bytesPerFrame)
+	ret := C.snd_pcm_writei(s.d.h, unsafe.Pointer(&s.scratch[0]), frames)
+	if ret == -C.EPIPE || ret == -C.ESTRPIPE {
+		s.d.recover(C.int(ret))
+	}
+}
+
+// serviceRead reads available samples from the device and hands them to the user callback.
+func (e *EventLoop) serviceRead(s *eventStream) {
+	frames := C.snd_pcm_uframes_t(len(s.scratch) / s.bytesPerFrame)
+	ret := C.snd_pcm_readi(s.d.h, unsafe.Pointer(&s.scratch[0]), frames)
+	if ret == -C.EPIPE || ret == -C.ESTRPIPE {
+		s.d.recover(C.int(ret))
+		return
+	}
+	if ret < 0 {
+		return
+	}
+	s.readCallback(s.scratch[:int(ret)*s.bytesPerFrame])
+}
+
+// recoverStream maps a POLLERR/POLLNVAL condition observed by Run to the underlying ALSA
+// error code and recovers s.d via the shared device.recover ladder (the same one Read/Write
+// use), so RecoveryPolicy.OnXrun fires and suspend handling sleeps between retries instead
+// of busy-spinning the event loop.
+func recoverStream(s *eventStream) {
+	switch C.snd_pcm_state(s.d.h) {
+	case C.SND_PCM_STATE_XRUN:
+		s.d.recover(-C.EPIPE)
+	case C.SND_PCM_STATE_SUSPENDED:
+		s.d.recover(-C.ESTRPIPE)
+	}
+}
+
+// pcmPollDescriptors returns the pollfd set ALSA wants polled for h.
+func pcmPollDescriptors(h *C.snd_pcm_t) ([]C.struct_pollfd, error) {
+	count := C.snd_pcm_poll_descriptors_count(h)
+	if count <= 0 {
+		return nil, fmt.Errorf("alsa: device reports no poll descriptors")
+	}
+	pfds := make([]C.struct_pollfd, count)
+	n := C.snd_pcm_poll_descriptors(h, &pfds[0], C.uint(count))
+	if n < 0 {
+		return nil, createError("could not get poll descriptors", n)
+	}
+	return pfds[:n], nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2015-2016 Cocoon Labs Ltd.
+//
+// See LICENSE file for terms and conditions.
+
+package alsa
+
+import "time"
+
+/*
+#include <alsa/asoundlib.h>
+*/
+import "C"
+
+// XrunKind identifies the kind of condition a RecoveryPolicy's OnXrun callback is reporting.
+type XrunKind int
+
+const (
+	// XrunUnderrun is reported when a playback device runs out of data to send.
+	XrunUnderrun XrunKind = iota
+	// XrunOverrun is reported when a capture device's buffer fills before it is read.
+	XrunOverrun
+	// XrunSuspend is reported when the device has been suspended by the kernel (e.g. on
+	// system sleep) and is being resumed.
+	XrunSuspend
+)
+
+// RecoveryPolicy controls how a device recovers from xruns (-EPIPE) and suspends
+// (-ESTRPIPE), and lets callers observe those events for telemetry.
+type RecoveryPolicy struct {
+	// MaxUnderrunRetries is the number of times Read/Write will transparently recover
+	// from an xrun and retry the operation before giving up and returning the
+	// ErrUnderrun/ErrOverrun error to the caller. Zero means don't retry.
+	MaxUnderrunRetries int
+	// SuspendPollInterval is how long to sleep between snd_pcm_resume attempts while the
+	// device is suspended.
+	SuspendPollInterval time.Duration
+	// OnXrun, if set, is called whenever recover handles an xrun or suspend condition.
+	OnXrun func(kind XrunKind)
+}
+
+// defaultRecoveryPolicy is used by devices that haven't called SetRecoveryPolicy.
+var defaultRecoveryPolicy = RecoveryPolicy{
+	SuspendPollInterval: 100 * time.Millisecond,
+}
+
+// SetRecoveryPolicy configures how d recovers from xruns and suspends. It may be called
+// before or after the device starts streaming; the store is not synchronized with a Read,
+// Write or EventLoop using the device on another goroutine.
+func (d *device) SetRecoveryPolicy(policy RecoveryPolicy) {
+	d.recoveryPolicy = &policy
+}
+
+func (d *device) policy() *RecoveryPolicy {
+	if d.recoveryPolicy != nil {
+		return d.recoveryPolicy
+	}
+	return &defaultRecoveryPolicy
+}
+
+// recover implements the canonical ALSA recovery ladder for the error ret returned by a
+// snd_pcm_readi/writei call: -EPIPE is recovered with snd_pcm_prepare, -ESTRPIPE is
+// recovered by polling snd_pcm_resume until the device stops reporting -EAGAIN and then
+// falling back to snd_pcm_prepare. Any other error is returned wrapped.
+func (d *device) recover(ret C.int) error {
+	policy := d.policy()
+	switch ret {
+	case -C.EPIPE:
+		kind := XrunUnderrun
+		if !d.playback {
+			kind = XrunOverrun
+		}
+		if policy.OnXrun != nil {
+			policy.OnXrun(kind)
+		}
+		if r := C.snd_pcm_prepare(d.h); r < 0 {
+			return createError("could not recover from xrun", r)
+		}
+		return nil
+	case -C.ESTRPIPE:
+		if policy.OnXrun != nil {
+			policy.OnXrun(XrunSuspend)
+		}
+		r := C.snd_pcm_resume(d.h)
+		for r == -C.EAGAIN {
+			time.Sleep(policy.SuspendPollInterval)
+			r = C.snd_pcm_resume(d.h)
+		}
+		if r < 0 {
+			if r = C.snd_pcm_prepare(d.h); r < 0 {
+				return createError("could not recover from suspend", r)
+			}
+		}
+		return nil
+	default:
+		return createError("alsa error", ret)
+	}
+}
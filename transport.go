@@ -0,0 +1,88 @@
+// Copyright 2015-2016 Cocoon Labs Ltd.
+//
+// See LICENSE file for terms and conditions.
+
+package alsa
+
+import "time"
+
+/*
+#include <alsa/asoundlib.h>
+*/
+import "C"
+
+// CanPause reports whether the device supports true pausing (snd_pcm_hw_params_can_pause,
+// checked once at open time). When it returns false, Pause falls back to stopping and
+// re-preparing the stream, which discards any samples still queued.
+func (d *device) CanPause() bool {
+	return d.canPause
+}
+
+// Pause stops the device from transferring samples. On hardware that supports pausing (see
+// CanPause) the data already queued is preserved and playback/capture picks up where it left
+// off on Resume. On hardware that doesn't, Pause falls back to stopping and re-preparing the
+// stream, which discards any samples still queued — check CanPause first if that loss
+// matters to the caller.
+func (d *device) Pause() error {
+	if d.canPause {
+		if ret := C.snd_pcm_pause(d.h, 1); ret < 0 {
+			return createError("could not pause device", ret)
+		}
+		return nil
+	}
+	if ret := C.snd_pcm_drop(d.h); ret < 0 {
+		return createError("could not stop device", ret)
+	}
+	if ret := C.snd_pcm_prepare(d.h); ret < 0 {
+		return createError("could not prepare device", ret)
+	}
+	return nil
+}
+
+// Resume undoes a previous call to Pause.
+func (d *device) Resume() error {
+	if d.canPause {
+		if ret := C.snd_pcm_pause(d.h, 0); ret < 0 {
+			return createError("could not resume device", ret)
+		}
+	}
+	return nil
+}
+
+// Drain blocks until all pending samples have been played. For a capture device it stops
+// capturing; samples already buffered remain available to Read.
+func (d *device) Drain() error {
+	if ret := C.snd_pcm_drain(d.h); ret < 0 {
+		return createError("could not drain device", ret)
+	}
+	return nil
+}
+
+// Reset discards any pending samples by reducing the device's delay to zero. It does not
+// change the stream's state.
+func (d *device) Reset() error {
+	if ret := C.snd_pcm_reset(d.h); ret < 0 {
+		return createError("could not reset device", ret)
+	}
+	return nil
+}
+
+// Delay returns the current latency between the application and the audio hardware.
+func (d *device) Delay() (time.Duration, error) {
+	var delayFrames C.snd_pcm_sframes_t
+	if ret := C.snd_pcm_delay(d.h, &delayFrames); ret < 0 {
+		return 0, createError("could not get device delay", ret)
+	}
+	seconds := float64(delayFrames) / float64(d.Rate)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// AvailUpdate returns the number of frames currently available to be written (for a
+// playback device) or read (for a capture device) without blocking.
+func (d *device) AvailUpdate() (int, error) {
+	avail := C.snd_pcm_avail_update(d.h)
+	if avail < 0 {
+		return 0, createError("could not get available frames", C.int(avail))
+	}
+	return int(avail), nil
+}